@@ -0,0 +1,124 @@
+// Package otobserver attaches perfevents counters to spans via the
+// OpenTracing go-observer bridge.
+package otobserver
+
+import (
+	"math/rand"
+	"runtime"
+	"syscall"
+
+	observer "github.com/opentracing-contrib/go-observer"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/hkshaw1990/zipkin-go-opentracing/perfevents"
+)
+
+// Config selects which perf events to track per span and how heavily to
+// downsample.
+type Config struct {
+	// Events maps an exact operation name to the perf event names to open
+	// a group for on spans with that operation name.
+	Events map[string][]string
+
+	// Default is the event set used for operation names not present in
+	// Events. If both Default and the matching Events entry are empty,
+	// spans for that operation name are not profiled.
+	Default []string
+
+	// SampleRate profiles only 1 in SampleRate spans. 0 or 1 profiles
+	// every span.
+	SampleRate int
+}
+
+// Observer is a go-observer observer that attaches hardware perf counter
+// deltas to spans as tags: perf.cpu-cycles, perf.instructions, perf.cpi,
+// perf.cache-misses, one tag per event configured for the span's
+// operation name plus the derived perf.cpi ratio.
+type Observer struct {
+	cfg Config
+}
+
+// New returns an Observer configured by cfg.
+func New(cfg Config) *Observer {
+	return &Observer{cfg: cfg}
+}
+
+// OnStartSpan opens a perf event group for sp, pinned to the current
+// goroutine's OS thread. The same goroutine must call OnFinish.
+func (o *Observer) OnStartSpan(sp opentracing.Span, operationName string, options opentracing.StartSpanOptions) (observer.SpanObserver, bool) {
+	events := o.eventsFor(operationName)
+	if len(events) == 0 || !o.shouldSample() {
+		return noopSpanObserver{}, false
+	}
+
+	runtime.LockOSThread()
+	tid := syscall.Gettid()
+
+	group, err := perfevents.OpenGroup(events, tid, -1, 0)
+	if err == -1 {
+		runtime.UnlockOSThread()
+		return noopSpanObserver{}, false
+	}
+
+	return &spanObserver{span: sp, group: group}, true
+}
+
+// eventsFor returns the perf event names configured for operationName,
+// falling back to the observer's default set.
+func (o *Observer) eventsFor(operationName string) []string {
+	if events, ok := o.cfg.Events[operationName]; ok {
+		return events
+	}
+	return o.cfg.Default
+}
+
+// shouldSample reports whether the current span should be profiled, given
+// the observer's SampleRate.
+func (o *Observer) shouldSample() bool {
+	if o.cfg.SampleRate <= 1 {
+		return true
+	}
+	return rand.Intn(o.cfg.SampleRate) == 0
+}
+
+// spanObserver carries the perf event group opened for one span.
+type spanObserver struct {
+	span  opentracing.Span
+	group *perfevents.PerfEventGroup
+}
+
+func (so *spanObserver) OnSetOperationName(operationName string) {}
+
+func (so *spanObserver) OnSetTag(key string, value interface{}) {}
+
+// OnFinish disables and reads the span's event group and attaches each
+// counter's delta, plus the derived cycles/instructions CPI, as span
+// tags.
+func (so *spanObserver) OnFinish(options opentracing.FinishOptions) {
+	defer runtime.UnlockOSThread()
+	defer so.group.Close()
+
+	values, err := so.group.ReadGroup()
+	if err == -1 {
+		return
+	}
+
+	for name, value := range values {
+		so.span.SetTag("perf."+name, value)
+	}
+
+	cycles, haveCycles := values["cpu-cycles"]
+	instructions, haveInstructions := values["instructions"]
+	if haveCycles && haveInstructions && instructions > 0 {
+		so.span.SetTag("perf.cpi", float64(cycles)/float64(instructions))
+	}
+}
+
+// noopSpanObserver is returned for spans that aren't profiled: their
+// operation name has no configured events, SampleRate skipped them, or
+// their event group could not be opened.
+type noopSpanObserver struct{}
+
+func (noopSpanObserver) OnSetOperationName(operationName string)    {}
+func (noopSpanObserver) OnSetTag(key string, value interface{})     {}
+func (noopSpanObserver) OnFinish(options opentracing.FinishOptions) {}
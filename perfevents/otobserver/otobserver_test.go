@@ -0,0 +1,44 @@
+package otobserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventsFor(t *testing.T) {
+	cfg := Config{
+		Events: map[string][]string{
+			"http.request": {"cpu-cycles", "instructions"},
+			"db.query":     {"cache-misses"},
+		},
+		Default: []string{"cpu-cycles"},
+	}
+	o := New(cfg)
+
+	cases := []struct {
+		name          string
+		operationName string
+		want          []string
+	}{
+		{"exact match", "http.request", []string{"cpu-cycles", "instructions"}},
+		{"another exact match", "db.query", []string{"cache-misses"}},
+		{"falls back to default", "unconfigured.op", []string{"cpu-cycles"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := o.eventsFor(c.operationName)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("eventsFor(%q) = %v, want %v", c.operationName, got, c.want)
+			}
+		})
+	}
+}
+
+func TestEventsForNoDefault(t *testing.T) {
+	o := New(Config{Events: map[string][]string{"http.request": {"cpu-cycles"}}})
+
+	if got := o.eventsFor("unconfigured.op"); len(got) != 0 {
+		t.Errorf("eventsFor(unconfigured) = %v, want empty", got)
+	}
+}
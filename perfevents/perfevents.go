@@ -69,6 +69,11 @@ const (
 	PERF_TYPE_SOFTWARE = 1
 )
 
+// PERF_FLAG_PID_CGROUP tells perf_event_open to treat the pid argument as
+// a cgroupfs directory file descriptor instead of a process id, scoping
+// the event to every task in that cgroup. See OpenEventOnCgroup.
+const PERF_FLAG_PID_CGROUP = 1 << 2
+
 // List of generic events supported (from linux/perf_event.h)
 const (
 	PERF_HW_CPU_CYCLES          = 0
@@ -82,20 +87,22 @@ const (
 
 // EventConfigType : The configuration struct for an event
 type EventConfigType struct {
-	typeHw uint32
-	config uint64
+	typeHw  uint32
+	config  uint64
+	config1 uint64
+	config2 uint64
 }
 
 // Initializes the event list
 func initEventList() map[string]EventConfigType {
 	return map[string]EventConfigType{
-		"cpu-cycles":              {PERF_TYPE_HARDWARE, PERF_HW_CPU_CYCLES},
-		"instructions":        {PERF_TYPE_HARDWARE, PERF_HW_INSTRUCTIONS},
-		"cache-references":    {PERF_TYPE_HARDWARE, PERF_HW_CACHE_REF},
-		"cache-misses":        {PERF_TYPE_HARDWARE, PERF_HW_CACHE_MISSES},
-		"branch-instructions": {PERF_TYPE_HARDWARE, PERF_HW_BRANCH_INSTRUCTIONS},
-		"branch-misses":       {PERF_TYPE_HARDWARE, PERF_HW_BRANCH_MISSES},
-		"bus-cycles":          {PERF_TYPE_HARDWARE, PERF_HW_BUS_CYCLES},
+		"cpu-cycles":              {PERF_TYPE_HARDWARE, PERF_HW_CPU_CYCLES, 0, 0},
+		"instructions":        {PERF_TYPE_HARDWARE, PERF_HW_INSTRUCTIONS, 0, 0},
+		"cache-references":    {PERF_TYPE_HARDWARE, PERF_HW_CACHE_REF, 0, 0},
+		"cache-misses":        {PERF_TYPE_HARDWARE, PERF_HW_CACHE_MISSES, 0, 0},
+		"branch-instructions": {PERF_TYPE_HARDWARE, PERF_HW_BRANCH_INSTRUCTIONS, 0, 0},
+		"branch-misses":       {PERF_TYPE_HARDWARE, PERF_HW_BRANCH_MISSES, 0, 0},
+		"bus-cycles":          {PERF_TYPE_HARDWARE, PERF_HW_BUS_CYCLES, 0, 0},
 	}
 }
 
@@ -103,6 +110,8 @@ func setupPerfEventAttr(eventConfig EventConfigType) PerfEventAttr {
 	var eventAttr PerfEventAttr
 	eventAttr.type_hw = eventConfig.typeHw
 	eventAttr.config = eventConfig.config
+	eventAttr.config1 = eventConfig.config1
+	eventAttr.config2 = eventConfig.config2
 	eventAttr.size_s = uint32(unsafe.Sizeof(eventAttr))
 	eventAttr.properties = setBit(eventAttr.properties, DISABLED)
 	eventAttr.properties = setBit(eventAttr.properties, EXCLUDE_KERNEL)
@@ -111,29 +120,35 @@ func setupPerfEventAttr(eventConfig EventConfigType) PerfEventAttr {
 	return eventAttr
 }
 
+// resolveEvent is the libpfm4-backed fallback resolver, wired up by the
+// libpfm build tag's init(); nil without that tag.
+var resolveEvent func(string) (EventConfigType, int)
+
 func fetchPerfEventAttr(event string) (PerfEventAttr, int) {
 	var eventAttr PerfEventAttr
 	evList := initEventList()
 	evConf, ok := evList[event]
-	if ok == false {
-		//fmt.Println("`event not supported`")
-		return eventAttr, -1
+	if !ok {
+		if resolveEvent == nil {
+			//fmt.Println("`event not supported`")
+			return eventAttr, -1
+		}
+		resolved, err := resolveEvent(event)
+		if err == -1 {
+			return eventAttr, -1
+		}
+		evConf = resolved
 	}
 	return setupPerfEventAttr(evConf), 0
 }
 
-// Perf IOCTL operations for x86
-const (
-	PERF_IOC_RESET_X86   = 0x2403
-	PERF_IOC_ENABLE_X86  = 0x2400
-	PERF_IOC_DISABLE_X86 = 0x2401
-)
-
-// Perf IOCTL operations for powerpc
+// Bit fields for PerfEventAttr.read_format (from linux/perf_event.h). These
+// control the layout of the buffer returned by a read() on the event fd.
 const (
-	PERF_IOC_RESET_PPC   = 0x20002403
-	PERF_IOC_ENABLE_PPC  = 0x20002400
-	PERF_IOC_DISABLE_PPC = 0x20002401
+	PERF_FORMAT_TOTAL_TIME_ENABLED = 1 << 0
+	PERF_FORMAT_TOTAL_TIME_RUNNING = 1 << 1
+	PERF_FORMAT_ID                 = 1 << 2
+	PERF_FORMAT_GROUP              = 1 << 3
 )
 
 // PerfEventInfo holds the file descriptor for a perf event
@@ -141,6 +156,18 @@ type PerfEventInfo struct {
 	EventName string
 	Fd        int
 	Data      uint64
+
+	// pid, cpu, groupFd and flags are the perf_event_open arguments the
+	// event was last opened with, kept so EnableSampling can reopen in
+	// sampling mode against the same target instead of defaulting to self.
+	pid     int
+	cpu     int
+	groupFd int
+	flags   uint64
+
+	// sampling holds the mmap'd ring buffer state once EnableSampling has
+	// been called on this event; nil for a plain counting event.
+	sampling *samplingState
 }
 
 // FetchPerfEventAttr is the same as that of the independent one, just to maintain consistency, this method is defined
@@ -199,6 +226,11 @@ func (event *PerfEventInfo) DisableClose() int {
 		return err
 	}
 
+	if event.sampling != nil {
+		syscall.Munmap(event.sampling.data)
+		event.sampling = nil
+	}
+
 	errClose := syscall.Close(int(event.Fd))
 	if errClose != nil {
 		return -1
@@ -224,6 +256,10 @@ func (event *PerfEventInfo) OpenEvent(eventAttr PerfEventAttr, pid int, cpu int,
 		return -1
 	}
 	event.Fd = int(fd)
+	event.pid = pid
+	event.cpu = cpu
+	event.groupFd = group_fd
+	event.flags = flags
 	return 0
 }
 
@@ -233,7 +269,7 @@ func (event *PerfEventInfo) ResetEvent() int {
 		fmt.Println("File descriptor is not set")
 		return -1
 	}
-	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_RESET_X86), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_RESET), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
 	//fmt.Println(err)
 	if err != 0 {
 		return -1
@@ -247,7 +283,7 @@ func (event *PerfEventInfo) EnableEvent() int {
 		fmt.Println("File descriptor is not set")
 		return -1
 	}
-	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_ENABLE_X86), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_ENABLE), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
 	if err != 0 {
 		return -1
 	}
@@ -261,7 +297,7 @@ func (event *PerfEventInfo) DisableEvent() int {
 		fmt.Println("File descriptor is not set")
 		return -1
 	}
-	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_DISABLE_X86), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_DISABLE), uintptr(0), uintptr(0), uintptr(0), uintptr(0))
 	if err != 0 {
 		return -1
 	}
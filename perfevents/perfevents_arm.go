@@ -0,0 +1,14 @@
+//go:build arm
+// +build arm
+
+package perfevents
+
+// Perf event ioctl numbers for 32-bit arm. arm shares the generic
+// asm-generic/ioctl.h encoding with x86 and arm64, so these numbers are
+// identical to theirs.
+const (
+	PERF_IOC_RESET   = 0x2403
+	PERF_IOC_ENABLE  = 0x2400
+	PERF_IOC_DISABLE = 0x2401
+	PERF_IOC_ID      = 0x80082407
+)
@@ -0,0 +1,14 @@
+//go:build arm64
+// +build arm64
+
+package perfevents
+
+// Perf event ioctl numbers for arm64. arm64 shares the generic
+// asm-generic/ioctl.h direction/size encoding with x86, so these numbers
+// are identical to the x86 ones.
+const (
+	PERF_IOC_RESET   = 0x2403
+	PERF_IOC_ENABLE  = 0x2400
+	PERF_IOC_DISABLE = 0x2401
+	PERF_IOC_ID      = 0x80082407
+)
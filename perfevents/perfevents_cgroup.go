@@ -0,0 +1,27 @@
+package perfevents
+
+import "syscall"
+
+// OpenEventOnCgroup opens a perf event for every name in events, scoped to
+// cgroupPath instead of a single pid. Call once per CPU to monitor.
+func OpenEventOnCgroup(cgroupPath string, cpu int, events []string) ([]*PerfEventInfo, int) {
+	cgroupFd, err := syscall.Open(cgroupPath, syscall.O_RDONLY, 0)
+	if err != nil {
+		return nil, -1
+	}
+	defer syscall.Close(cgroupFd)
+
+	infos := make([]*PerfEventInfo, 0, len(events))
+	for _, name := range events {
+		event := &PerfEventInfo{}
+		if err := event.InitOpenEventEnable(name, cgroupFd, cpu, -1, PERF_FLAG_PID_CGROUP); err != 0 {
+			for _, opened := range infos {
+				opened.DisableClose()
+			}
+			return nil, -1
+		}
+		infos = append(infos, event)
+	}
+
+	return infos, 0
+}
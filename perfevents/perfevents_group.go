@@ -0,0 +1,143 @@
+package perfevents
+
+import (
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+)
+
+// PerfEventGroup is a set of counters sharing one leader fd, read
+// atomically via ReadGroup.
+type PerfEventGroup struct {
+	Leader  *PerfEventInfo
+	Members []*PerfEventInfo
+	ids     map[uint64]string
+}
+
+// OpenGroup opens events as a single hardware event group, the first as
+// leader (group_fd=-1), the rest with group_fd set to the leader's fd.
+func OpenGroup(events []string, pid int, cpu int, flags uint64) (*PerfEventGroup, int) {
+	if len(events) == 0 {
+		return nil, -1
+	}
+
+	group := &PerfEventGroup{ids: make(map[uint64]string)}
+
+	leaderAttr, err := fetchPerfEventAttr(events[0])
+	if err == -1 {
+		return nil, -1
+	}
+	leaderAttr.read_format = PERF_FORMAT_GROUP | PERF_FORMAT_TOTAL_TIME_ENABLED | PERF_FORMAT_TOTAL_TIME_RUNNING | PERF_FORMAT_ID
+
+	leader := &PerfEventInfo{}
+	if err := leader.OpenEvent(leaderAttr, pid, cpu, -1, flags); err != 0 {
+		return nil, -1
+	}
+	leader.EventName = events[0]
+	group.Leader = leader
+	group.Members = append(group.Members, leader)
+	if err := group.recordID(leader); err != 0 {
+		group.Close()
+		return nil, -1
+	}
+
+	for _, name := range events[1:] {
+		memberAttr, err := fetchPerfEventAttr(name)
+		if err == -1 {
+			group.Close()
+			return nil, -1
+		}
+		memberAttr.read_format = leaderAttr.read_format
+
+		member := &PerfEventInfo{}
+		if err := member.OpenEvent(memberAttr, pid, cpu, leader.Fd, flags); err != 0 {
+			group.Close()
+			return nil, -1
+		}
+		member.EventName = name
+		group.Members = append(group.Members, member)
+		if err := group.recordID(member); err != 0 {
+			group.Close()
+			return nil, -1
+		}
+	}
+
+	if err := leader.ResetEvent(); err != 0 {
+		group.Close()
+		return nil, -1
+	}
+	if err := leader.EnableEvent(); err != 0 {
+		group.Close()
+		return nil, -1
+	}
+
+	return group, 0
+}
+
+// recordID asks the kernel for the read_format id it assigned event.
+func (group *PerfEventGroup) recordID(event *PerfEventInfo) int {
+	var id uint64
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(event.Fd), uintptr(PERF_IOC_ID), uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return -1
+	}
+	group.ids[id] = event.EventName
+	return 0
+}
+
+// ReadGroup reads every counter in the group in one syscall, keyed by
+// event name.
+func (group *PerfEventGroup) ReadGroup() (map[string]uint64, int) {
+	bufLen := 8 * (3 + 2*len(group.Members))
+	readBuf := make([]byte, bufLen)
+	n, err := syscall.Read(group.Leader.Fd, readBuf)
+	if err != nil || n != bufLen {
+		return nil, -1
+	}
+
+	return parseGroupRead(readBuf, group.ids), 0
+}
+
+// parseGroupRead decodes a PERF_FORMAT_GROUP|..._ID read() buffer (nr,
+// time_enabled, time_running, then nr {value,id} pairs) keyed by ids.
+func parseGroupRead(buf []byte, ids map[uint64]string) map[string]uint64 {
+	nr := binary.LittleEndian.Uint64(buf[0:8])
+	timeEnabled := binary.LittleEndian.Uint64(buf[8:16])
+	timeRunning := binary.LittleEndian.Uint64(buf[16:24])
+
+	values := make(map[string]uint64, nr)
+	offset := 24
+	for i := uint64(0); i < nr; i++ {
+		value := binary.LittleEndian.Uint64(buf[offset : offset+8])
+		id := binary.LittleEndian.Uint64(buf[offset+8 : offset+16])
+		offset += 16
+
+		name, ok := ids[id]
+		if !ok {
+			continue
+		}
+		values[name] = scaleValue(value, timeEnabled, timeRunning)
+	}
+
+	return values
+}
+
+// Close disables and closes every file descriptor opened for the group.
+func (group *PerfEventGroup) Close() int {
+	ret := 0
+	for _, member := range group.Members {
+		if member.Fd > 1 && member.DisableClose() == -1 {
+			ret = -1
+		}
+	}
+	return ret
+}
+
+// scaleValue extrapolates value to what it would be had the event run
+// the whole window, correcting for PMU multiplexing.
+func scaleValue(value, timeEnabled, timeRunning uint64) uint64 {
+	if timeRunning == 0 || timeEnabled == timeRunning {
+		return value
+	}
+	return uint64(float64(value) * (float64(timeEnabled) / float64(timeRunning)))
+}
@@ -0,0 +1,78 @@
+package perfevents
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestScaleValue(t *testing.T) {
+	cases := []struct {
+		name                            string
+		value, timeEnabled, timeRunning uint64
+		want                            uint64
+	}{
+		{"fully scheduled", 1000, 100, 100, 1000},
+		{"never scheduled", 1000, 100, 0, 1000},
+		{"half scheduled", 1000, 100, 50, 2000},
+		{"quarter scheduled", 400, 100, 25, 1600},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := scaleValue(c.value, c.timeEnabled, c.timeRunning)
+			if got != c.want {
+				t.Errorf("scaleValue(%d, %d, %d) = %d, want %d", c.value, c.timeEnabled, c.timeRunning, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseGroupRead(t *testing.T) {
+	// Two counters, fully scheduled (time_enabled == time_running).
+	buf := make([]byte, 8*(3+2*2))
+	binary.LittleEndian.PutUint64(buf[0:8], 2)     // nr
+	binary.LittleEndian.PutUint64(buf[8:16], 100)  // time_enabled
+	binary.LittleEndian.PutUint64(buf[16:24], 100) // time_running
+	binary.LittleEndian.PutUint64(buf[24:32], 12345)
+	binary.LittleEndian.PutUint64(buf[32:40], 1) // id 1
+	binary.LittleEndian.PutUint64(buf[40:48], 6789)
+	binary.LittleEndian.PutUint64(buf[48:56], 2) // id 2
+
+	ids := map[uint64]string{1: "cpu-cycles", 2: "instructions"}
+	got := parseGroupRead(buf, ids)
+
+	want := map[string]uint64{"cpu-cycles": 12345, "instructions": 6789}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGroupRead() = %v, want %v", got, want)
+	}
+}
+
+func TestParseGroupReadUnknownID(t *testing.T) {
+	buf := make([]byte, 8*(3+2*1))
+	binary.LittleEndian.PutUint64(buf[0:8], 1)
+	binary.LittleEndian.PutUint64(buf[8:16], 100)
+	binary.LittleEndian.PutUint64(buf[16:24], 100)
+	binary.LittleEndian.PutUint64(buf[24:32], 42)
+	binary.LittleEndian.PutUint64(buf[32:40], 99) // no event registered for id 99
+
+	got := parseGroupRead(buf, map[uint64]string{})
+	if len(got) != 0 {
+		t.Errorf("parseGroupRead() = %v, want empty map for unknown id", got)
+	}
+}
+
+func TestParseGroupReadMultiplexed(t *testing.T) {
+	buf := make([]byte, 8*(3+2*1))
+	binary.LittleEndian.PutUint64(buf[0:8], 1)
+	binary.LittleEndian.PutUint64(buf[8:16], 200)  // time_enabled
+	binary.LittleEndian.PutUint64(buf[16:24], 100) // time_running: scheduled half the time
+	binary.LittleEndian.PutUint64(buf[24:32], 500)
+	binary.LittleEndian.PutUint64(buf[32:40], 7)
+
+	got := parseGroupRead(buf, map[uint64]string{7: "cache-misses"})
+	want := map[string]uint64{"cache-misses": 1000}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGroupRead() = %v, want %v", got, want)
+	}
+}
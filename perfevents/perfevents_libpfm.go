@@ -0,0 +1,83 @@
+//go:build libpfm
+// +build libpfm
+
+package perfevents
+
+/*
+#cgo LDFLAGS: -lpfm
+#include <stdlib.h>
+#include <string.h>
+#include <perfmon/pfmlib.h>
+#include <perfmon/pfmlib_perf_event.h>
+
+static int perfevents_pfm_encode(const char *name, unsigned int *type_hw,
+                                  unsigned long long *config,
+                                  unsigned long long *config1,
+                                  unsigned long long *config2) {
+	struct perf_event_attr attr;
+	pfm_perf_encode_arg_t arg;
+
+	memset(&attr, 0, sizeof(attr));
+	memset(&arg, 0, sizeof(arg));
+	arg.attr = &attr;
+	arg.size = sizeof(arg);
+
+	int ret = pfm_get_os_event_encoding(name, PFM_PLM0 | PFM_PLM3, PFM_OS_PERF_EVENT_EXT, &arg);
+	if (ret != PFM_SUCCESS) {
+		return ret;
+	}
+
+	*type_hw = attr.type;
+	*config = attr.config;
+	*config1 = attr.config1;
+	*config2 = attr.config2;
+	return PFM_SUCCESS;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+func init() {
+	resolveEvent = ResolveEvent
+}
+
+var libpfmInitOnce sync.Once
+var libpfmInitErr int
+
+func ensureLibpfmInitialized() int {
+	libpfmInitOnce.Do(func() {
+		if ret := C.pfm_initialize(); ret != C.PFM_SUCCESS {
+			fmt.Println("libpfm: pfm_initialize failed")
+			libpfmInitErr = -1
+		}
+	})
+	return libpfmInitErr
+}
+
+// ResolveEvent translates a libpfm4 event name into an EventConfigType.
+func ResolveEvent(name string) (EventConfigType, int) {
+	if err := ensureLibpfmInitialized(); err == -1 {
+		return EventConfigType{}, -1
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var typeHw C.uint
+	var config, config1, config2 C.ulonglong
+	if ret := C.perfevents_pfm_encode(cName, &typeHw, &config, &config1, &config2); ret != C.PFM_SUCCESS {
+		return EventConfigType{}, -1
+	}
+
+	return EventConfigType{
+		typeHw:  uint32(typeHw),
+		config:  uint64(config),
+		config1: uint64(config1),
+		config2: uint64(config2),
+	}, 0
+}
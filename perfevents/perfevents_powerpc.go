@@ -0,0 +1,17 @@
+//go:build ppc64 || ppc64le
+// +build ppc64 ppc64le
+
+package perfevents
+
+// Perf event ioctl numbers for powerpc. powerpc's asm-generic/ioctl.h
+// override shifts direction by 29 bits with dir=NONE=1, READ=2, WRITE=4
+// (vs x86/arm's dir<<30, NONE=0, READ=2, WRITE=1), so these aren't just
+// the x86 values: RESET/ENABLE/DISABLE are _IO (dir=NONE=1 -> 1<<29 =
+// 0x20000000) plus the x86 nr, and ID is _IOR('$',7,u64) (dir=READ=2 ->
+// 2<<29 = 0x40000000, size=8<<16, type '$'=0x24<<8, nr=7).
+const (
+	PERF_IOC_RESET   = 0x20002403
+	PERF_IOC_ENABLE  = 0x20002400
+	PERF_IOC_DISABLE = 0x20002401
+	PERF_IOC_ID      = 0x40082407
+)
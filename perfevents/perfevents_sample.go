@@ -0,0 +1,268 @@
+package perfevents
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Bit fields for PerfEventAttr.sample_type (from linux/perf_event.h). These
+// select which fields EnableSampling's ring buffer records carry.
+const (
+	PERF_SAMPLE_IP        = 1 << 0
+	PERF_SAMPLE_TID       = 1 << 1
+	PERF_SAMPLE_TIME      = 1 << 2
+	PERF_SAMPLE_ADDR      = 1 << 3
+	PERF_SAMPLE_ID        = 1 << 6
+	PERF_SAMPLE_CPU       = 1 << 7
+	PERF_SAMPLE_PERIOD    = 1 << 8
+	PERF_SAMPLE_STREAM_ID = 1 << 9
+	PERF_SAMPLE_CALLCHAIN = 1 << 5
+	PERF_SAMPLE_RAW       = 1 << 10
+)
+
+// perf_event_header record types (from linux/perf_event.h).
+const (
+	PERF_RECORD_MMAP   = 1
+	PERF_RECORD_LOST   = 2
+	PERF_RECORD_COMM   = 3
+	PERF_RECORD_SAMPLE = 9
+)
+
+// perfEventMmapPage mirrors struct perf_event_mmap_page: the header page
+// the kernel places at the start of the mmap'd ring buffer. Only the
+// fields PollSamples needs are named; the gap between size and data_head
+// is the kernel's reserved padding that keeps data_head at byte offset
+// 1024 across kernel versions.
+type perfEventMmapPage struct {
+	version       uint32
+	compatVersion uint32
+	lock          uint32
+	index         uint32
+	offset        int64
+	timeEnabled   uint64
+	timeRunning   uint64
+	capabilities  uint64
+	pmcWidth      uint16
+	timeShift     uint16
+	timeMult      uint32
+	timeOffset    uint64
+	timeZero      uint64
+	size          uint32
+	reserved      [118*8 + 4]byte
+	dataHead      uint64
+	dataTail      uint64
+	dataOffset    uint64
+	dataSize      uint64
+	auxHead       uint64
+	auxTail       uint64
+	auxOffset     uint64
+	auxSize       uint64
+}
+
+// Sample is one decoded perf_event_header record read off an event's ring
+// buffer. For record type PERF_RECORD_SAMPLE, the fields populated depend
+// on the sample_type passed to EnableSampling; other record types only
+// carry Type.
+type Sample struct {
+	Type      uint32
+	IP        uint64
+	Pid       uint32
+	Tid       uint32
+	Time      uint64
+	Addr      uint64
+	CPU       uint32
+	Period    uint64
+	Callchain []uint64
+	Raw       []byte
+}
+
+// samplingState holds the mmap'd ring buffer for an event with sampling
+// enabled.
+type samplingState struct {
+	data       []byte
+	sampleType uint64
+}
+
+// EnableSampling switches event into sampling mode: it builds a fresh
+// PerfEventAttr for event.EventName with sample_type, sample_period and
+// wakeup_events set, reopens the event against its original pid/cpu/flags,
+// and mmaps (1+2^pages) pages at the resulting fd so PollSamples can
+// stream decoded records off it.
+func (event *PerfEventInfo) EnableSampling(sampleType uint64, samplePeriod uint64, pages int) int {
+	if event.EventName == "" {
+		return -1
+	}
+
+	eventAttr, err := fetchPerfEventAttr(event.EventName)
+	if err == -1 {
+		return -1
+	}
+	eventAttr.sample_type = sampleType
+	eventAttr.sample_period = samplePeriod
+	eventAttr.wakeup_events = 1
+
+	pid, cpu, flags := event.pid, event.cpu, event.flags
+	if event.Fd > 1 {
+		syscall.Close(event.Fd)
+		event.Fd = 0
+	}
+	if openErr := event.OpenEvent(eventAttr, pid, cpu, -1, flags); openErr != 0 {
+		return -1
+	}
+
+	ringPages := 1 + (1 << uint(pages))
+	data, mmapErr := syscall.Mmap(event.Fd, 0, ringPages*syscall.Getpagesize(), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if mmapErr != nil {
+		return -1
+	}
+	event.sampling = &samplingState{data: data, sampleType: sampleType}
+
+	if event.ResetEvent() != 0 || event.EnableEvent() != 0 {
+		return -1
+	}
+
+	return 0
+}
+
+// PollSamples streams decoded ring-buffer records from event until ctx is
+// canceled. The returned channel is closed when polling stops; callers
+// should still call DisableClose to unmap the ring buffer and close the
+// fd once done.
+func (event *PerfEventInfo) PollSamples(ctx context.Context) <-chan Sample {
+	out := make(chan Sample)
+
+	go func() {
+		defer close(out)
+		if event.sampling == nil {
+			return
+		}
+
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !event.drainSamples(ctx, out) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// drainSamples decodes every record currently available in the ring
+// buffer and sends it on out, advancing data_tail as it goes. It returns
+// false if ctx was canceled while sending.
+func (event *PerfEventInfo) drainSamples(ctx context.Context, out chan<- Sample) bool {
+	state := event.sampling
+	header := (*perfEventMmapPage)(unsafe.Pointer(&state.data[0]))
+	dataStart := syscall.Getpagesize()
+	dataSize := len(state.data) - dataStart
+
+	dataHead := atomic.LoadUint64(&header.dataHead)
+	dataTail := header.dataTail
+
+	for dataTail < dataHead {
+		recOffset := dataStart + int(dataTail%uint64(dataSize))
+		recType := binary.LittleEndian.Uint32(state.data[recOffset : recOffset+4])
+		recSize := binary.LittleEndian.Uint16(state.data[recOffset+6 : recOffset+8])
+		if recSize == 0 {
+			break
+		}
+
+		rec := readRingRecord(state.data, dataStart, dataSize, dataTail, uint64(recSize))
+		dataTail += uint64(recSize)
+
+		sample := decodeRecord(recType, state.sampleType, rec)
+		select {
+		case out <- sample:
+		case <-ctx.Done():
+			atomic.StoreUint64(&header.dataTail, dataTail)
+			return false
+		}
+	}
+
+	atomic.StoreUint64(&header.dataTail, dataTail)
+	return true
+}
+
+// readRingRecord copies one record's bytes out of the ring buffer,
+// handling wraparound at the buffer boundary.
+func readRingRecord(data []byte, dataStart int, dataSize int, tail uint64, size uint64) []byte {
+	rec := make([]byte, size)
+	offset := int(tail % uint64(dataSize))
+	n := copy(rec, data[dataStart+offset:])
+	if uint64(n) < size {
+		copy(rec[n:], data[dataStart:])
+	}
+	return rec
+}
+
+// decodeRecord parses one ring buffer record's body (past its 8-byte
+// perf_event_header) according to the field order the kernel ABI uses for
+// PERF_RECORD_SAMPLE. Other record types carry no sample_type-dependent
+// body and are returned with only Type set.
+func decodeRecord(recType uint32, sampleType uint64, rec []byte) Sample {
+	sample := Sample{Type: recType}
+	if recType != PERF_RECORD_SAMPLE {
+		return sample
+	}
+
+	offset := 8
+	if sampleType&PERF_SAMPLE_IP != 0 {
+		sample.IP = binary.LittleEndian.Uint64(rec[offset:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_TID != 0 {
+		sample.Pid = binary.LittleEndian.Uint32(rec[offset:])
+		sample.Tid = binary.LittleEndian.Uint32(rec[offset+4:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_TIME != 0 {
+		sample.Time = binary.LittleEndian.Uint64(rec[offset:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_ADDR != 0 {
+		sample.Addr = binary.LittleEndian.Uint64(rec[offset:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_ID != 0 {
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_STREAM_ID != 0 {
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_CPU != 0 {
+		sample.CPU = binary.LittleEndian.Uint32(rec[offset:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_PERIOD != 0 {
+		sample.Period = binary.LittleEndian.Uint64(rec[offset:])
+		offset += 8
+	}
+	if sampleType&PERF_SAMPLE_CALLCHAIN != 0 {
+		nr := binary.LittleEndian.Uint64(rec[offset:])
+		offset += 8
+		sample.Callchain = make([]uint64, nr)
+		for i := uint64(0); i < nr; i++ {
+			sample.Callchain[i] = binary.LittleEndian.Uint64(rec[offset:])
+			offset += 8
+		}
+	}
+	if sampleType&PERF_SAMPLE_RAW != 0 {
+		size := binary.LittleEndian.Uint32(rec[offset:])
+		offset += 4
+		sample.Raw = append([]byte(nil), rec[offset:offset+int(size)]...)
+	}
+
+	return sample
+}
@@ -0,0 +1,88 @@
+package perfevents
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRecordNonSample(t *testing.T) {
+	cases := []uint32{PERF_RECORD_MMAP, PERF_RECORD_LOST, PERF_RECORD_COMM}
+	for _, recType := range cases {
+		got := decodeRecord(recType, PERF_SAMPLE_IP, []byte{0, 0, 0, 0, 0, 0, 0, 0})
+		want := Sample{Type: recType}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeRecord(%d, ...) = %+v, want %+v", recType, got, want)
+		}
+	}
+}
+
+func TestDecodeRecordSample(t *testing.T) {
+	sampleType := uint64(PERF_SAMPLE_IP | PERF_SAMPLE_TID | PERF_SAMPLE_TIME | PERF_SAMPLE_CALLCHAIN)
+
+	rec := make([]byte, 8+8+8+8+8+2*8)
+	binary.LittleEndian.PutUint64(rec[8:16], 0xdeadbeef) // IP
+	binary.LittleEndian.PutUint32(rec[16:20], 111)       // pid
+	binary.LittleEndian.PutUint32(rec[20:24], 222)       // tid
+	binary.LittleEndian.PutUint64(rec[24:32], 999)       // time
+	binary.LittleEndian.PutUint64(rec[32:40], 2)         // callchain nr
+	binary.LittleEndian.PutUint64(rec[40:48], 0x1)
+	binary.LittleEndian.PutUint64(rec[48:56], 0x2)
+
+	got := decodeRecord(PERF_RECORD_SAMPLE, sampleType, rec)
+	want := Sample{
+		Type:      PERF_RECORD_SAMPLE,
+		IP:        0xdeadbeef,
+		Pid:       111,
+		Tid:       222,
+		Time:      999,
+		Callchain: []uint64{0x1, 0x2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeRecord() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeRecordRaw(t *testing.T) {
+	sampleType := uint64(PERF_SAMPLE_RAW)
+
+	payload := []byte{0xaa, 0xbb, 0xcc}
+	rec := make([]byte, 8+4+len(payload))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(len(payload)))
+	copy(rec[12:], payload)
+
+	got := decodeRecord(PERF_RECORD_SAMPLE, sampleType, rec)
+	if !reflect.DeepEqual(got.Raw, payload) {
+		t.Errorf("decodeRecord() Raw = %v, want %v", got.Raw, payload)
+	}
+}
+
+func TestReadRingRecordNoWrap(t *testing.T) {
+	dataStart := 16
+	dataSize := 32
+	data := make([]byte, dataStart+dataSize)
+	want := []byte{1, 2, 3, 4, 5, 6}
+	copy(data[dataStart+4:], want)
+
+	got := readRingRecord(data, dataStart, dataSize, 4, uint64(len(want)))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readRingRecord() = %v, want %v", got, want)
+	}
+}
+
+func TestReadRingRecordWraps(t *testing.T) {
+	dataStart := 16
+	dataSize := 8
+	data := make([]byte, dataStart+dataSize)
+
+	// Record starts 2 bytes from the end of the ring and wraps around.
+	tail := uint64(dataSize - 2)
+	want := []byte{1, 2, 3, 4}
+	copy(data[dataStart+dataSize-2:], want[:2])
+	copy(data[dataStart:], want[2:])
+
+	got := readRingRecord(data, dataStart, dataSize, tail, uint64(len(want)))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readRingRecord() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build amd64 || 386
+// +build amd64 386
+
+package perfevents
+
+// Perf event ioctl numbers for x86/x86-64. PERF_IOC_ID is PERF_EVENT_IOC_ID,
+// used to recover the read_format id the kernel assigned an event so
+// ReadGroup can map combined-read values back to event names.
+const (
+	PERF_IOC_RESET   = 0x2403
+	PERF_IOC_ENABLE  = 0x2400
+	PERF_IOC_DISABLE = 0x2401
+	PERF_IOC_ID      = 0x80082407
+)